@@ -1,29 +1,108 @@
 package contentaddressable
 
 import (
+	"context"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
 )
 
 var (
 	AlreadyClosed = errors.New("Already closed.")
 	HasData       = errors.New("Destination file already has data.")
 	DefaultSuffix = "-temp"
+
+	// DefaultAlgorithm is used when a Config does not specify one, and the
+	// filename does not carry an algorithm prefix.
+	DefaultAlgorithm = "sha256"
+
+	// algorithms maps the prefix used in a filename (e.g. "sha1-<hex>") to a
+	// constructor for the matching hash.Hash implementation.
+	algorithms = map[string]func() hash.Hash{
+		"sha1":    sha1.New,
+		"sha256":  sha256.New,
+		"sha512":  sha512.New,
+		"blake2b": newBlake2b256,
+	}
+)
+
+func newBlake2b256() hash.Hash {
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		// Only occurs with a non-nil key of invalid length, which never
+		// happens here.
+		panic(err)
+	}
+	return h
+}
+
+// Oid identifies the expected contents of a File by hash algorithm and hex
+// digest, so callers can tell which algorithm produced it.
+type Oid struct {
+	Algorithm string
+	Hex       string
+}
+
+// String returns the "algorithm-hex" form used in content addressable
+// filenames, e.g. "sha1-da39a3ee...".
+func (o Oid) String() string {
+	return o.Algorithm + "-" + o.Hex
+}
+
+// Config controls how a File derives its expected Oid and hasher from a
+// filename.
+type Config struct {
+	// Algorithm selects a hash implementation by name ("sha1", "sha256",
+	// "sha512", or "blake2b"). Defaults to DefaultAlgorithm. If NewHash is
+	// set, Algorithm does not pick the hasher and only names Oid.Algorithm.
+	Algorithm string
+
+	// NewHash, if set, overrides Algorithm and any algorithm prefix in the
+	// filename, and constructs the hasher used to verify content, for
+	// algorithms this package doesn't know by name. Oid.Algorithm then reports
+	// Algorithm, or DefaultAlgorithm if Algorithm is empty, not the name of
+	// the NewHash algorithm.
+	NewHash func() hash.Hash
+}
+
+// fileState tracks how a File reached a terminal state, so Close() can tell
+// an explicit double-Close (an error) apart from a Close() that merely
+// follows a prior Accept() or Cancel() (a no-op).
+type fileState int
+
+const (
+	fileOpen fileState = iota
+	fileAccepted
+	fileCanceled
+	fileClosed
 )
 
 // File handles the atomic writing of a content addressable file.  It writes to
 // a temp file, and then renames to the final location after Accept().
 type File struct {
-	Oid          string
+	Oid          Oid
 	filename     string
 	tempFilename string
-	tempFile     *os.File
+	tempFile     FSFile
 	hasher       hash.Hash
+	state        fileState
+	fs           FS
+
+	// Durable, if true, makes Accept fsync the temp file's data before the
+	// rename and the destination directory entry after it, so a crash right
+	// after a successful Accept can't leave a missing or zero-length file.
+	// It costs an extra pair of syscalls per Accept, so it defaults to false.
+	Durable bool
 }
 
 // NewFile initializes a content addressable file for writing.  It is identical
@@ -37,14 +116,47 @@ func NewFile(filename string) (*File, error) {
 // filename when Accept() is called. The *File OID is taken from the base name
 // of the given filename.
 func NewWithSuffix(filename, suffix string) (*File, error) {
-	oid := filepath.Base(filename)
+	return newFile(OSFS{}, filename, suffix, Config{})
+}
+
+// NewWithConfig initializes a content addressable file for writing, using cfg
+// to pick the hash algorithm. It is identical to NewWithSuffixAndConfig,
+// except it uses DefaultSuffix as the suffix.
+func NewWithConfig(filename string, cfg Config) (*File, error) {
+	return newFile(OSFS{}, filename, DefaultSuffix, cfg)
+}
+
+// NewWithSuffixAndConfig initializes a content addressable file for writing,
+// using cfg to pick the hash algorithm. The expected Oid is parsed from the
+// base name of the given filename: if cfg.NewHash is set, it is used; else if
+// the base name has an "<algorithm>-" prefix recognized by this package (e.g.
+// "sha1-", "blake2b-"), that algorithm is used; otherwise cfg.Algorithm
+// applies, falling back to DefaultAlgorithm. A recognized prefix is stripped
+// from the expected digest in every case.
+func NewWithSuffixAndConfig(filename, suffix string, cfg Config) (*File, error) {
+	return newFile(OSFS{}, filename, suffix, cfg)
+}
+
+// NewWithFS initializes a content addressable file for writing, performing
+// all filesystem operations through fs instead of the local disk. It is
+// identical to NewWithConfig otherwise.
+func NewWithFS(fs FS, filename string, cfg Config) (*File, error) {
+	return newFile(fs, filename, DefaultSuffix, cfg)
+}
+
+func newFile(fs FS, filename, suffix string, cfg Config) (*File, error) {
+	oid, newHash, err := parseOid(filepath.Base(filename), cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := fs.MkdirAll(dir, 0755); err != nil {
 		return nil, err
 	}
 
 	tempFilename := filename + suffix
-	tempFile, err := os.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	tempFile, err := fs.OpenFile(tempFilename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
 		return nil, err
 	}
@@ -54,12 +166,47 @@ func NewWithSuffix(filename, suffix string) (*File, error) {
 		filename:     filename,
 		tempFilename: tempFilename,
 		tempFile:     tempFile,
-		hasher:       sha256.New(),
+		hasher:       newHash(),
+		fs:           fs,
 	}
 
 	return caw, nil
 }
 
+// parseOid derives the expected Oid and hasher constructor for base, a
+// filename's base name, given cfg. If base starts with a recognized
+// "<algorithm>-" prefix, the prefix is stripped, and that algorithm is used
+// unless cfg.NewHash is set; otherwise cfg (or DefaultAlgorithm) supplies it.
+func parseOid(base string, cfg Config) (Oid, func() hash.Hash, error) {
+	algorithm := cfg.Algorithm
+	newHash := cfg.NewHash
+
+	if i := strings.IndexByte(base, '-'); i > 0 {
+		if prefixHash, ok := algorithms[base[:i]]; ok {
+			if newHash == nil {
+				algorithm = base[:i]
+				newHash = prefixHash
+			}
+			base = base[i+1:]
+		}
+	}
+
+	if newHash == nil {
+		if algorithm == "" {
+			algorithm = DefaultAlgorithm
+		}
+		knownHash, ok := algorithms[algorithm]
+		if !ok {
+			return Oid{}, nil, fmt.Errorf("Unknown hash algorithm %q", algorithm)
+		}
+		newHash = knownHash
+	} else if algorithm == "" {
+		algorithm = DefaultAlgorithm
+	}
+
+	return Oid{Algorithm: algorithm, Hex: base}, newHash, nil
+}
+
 // Write sends data to the temporary file.
 func (w *File) Write(p []byte) (int, error) {
 	if w.Closed() {
@@ -70,57 +217,135 @@ func (w *File) Write(p []byte) (int, error) {
 	return w.tempFile.Write(p)
 }
 
-// Accept verifies the written content SHA-256 signature matches the given OID.
+// Accept verifies the written content's signature matches the given OID.
 // If it matches, the temp file is renamed to the destination filename.
 // Returns a bool indicating whether the destination file was created (if not,
 // someone else adding the same contents in parallel got there first), and
 // an error that might have occurred during the rename.
 func (w *File) Accept() (bool, error) {
+	return w.AcceptContext(context.Background())
+}
+
+// AcceptContext is like Accept, but honors ctx's cancellation while
+// finalizing: it is checked before summing the hash and again just before the
+// rename, so a caller that aborts a long-running operation (e.g. the HTTP
+// request that was streaming into this File) doesn't pay for either step.
+// On cancellation the temp file is discarded, same as Cancel().
+func (w *File) AcceptContext(ctx context.Context) (bool, error) {
 	if w.Closed() {
 		return false, AlreadyClosed
 	}
 
+	if err := ctx.Err(); err != nil {
+		w.discard(fileCanceled)
+		return false, err
+	}
+
 	sig := hex.EncodeToString(w.hasher.Sum(nil))
-	if sig != w.Oid {
-		return false, fmt.Errorf("Content mismatch.  Expected OID %s, got %s", w.Oid, sig)
+	if sig != w.Oid.Hex {
+		w.discard(fileCanceled)
+		return false, fmt.Errorf("Content mismatch.  Expected %s OID %s, got %s", w.Oid.Algorithm, w.Oid.Hex, sig)
+	}
+
+	if err := ctx.Err(); err != nil {
+		w.discard(fileCanceled)
+		return false, err
 	}
 
 	// Only bother renaming the temp file if the destination file doesn't already exist.
-	// Since the SHA-256 must match, we can be confident that the contents are identical.
-	if _, err := os.Stat(w.filename); err != nil {
+	// Since the hash must match, we can be confident that the contents are identical.
+	if _, err := w.fs.Stat(w.filename); err != nil {
+		if w.Durable {
+			if err := w.tempFile.Sync(); err != nil {
+				w.discard(fileCanceled)
+				return false, err
+			}
+		}
+
 		w.tempFile.Close()
 		w.tempFile = nil
+		w.state = fileAccepted
 
 		// rename the temp file to the real file
-		return true, os.Rename(w.tempFilename, w.filename)
+		if err := w.fs.Rename(w.tempFilename, w.filename); err != nil {
+			return true, err
+		}
+
+		if w.Durable {
+			if err := w.syncDir(filepath.Dir(w.filename)); err != nil {
+				return true, err
+			}
+		}
+
+		return true, nil
 	}
 
-	return false, w.Close()
+	w.state = fileAccepted
+	return false, w.discard(fileAccepted)
 }
 
-// Close cleans up the internal file objects.
-func (w *File) Close() error {
+// syncDir fsyncs the directory entry at dir, so a rename into that directory
+// is durable across a crash. It is a no-op on Windows, which doesn't support
+// syncing directories.
+func (w *File) syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := w.fs.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Cancel unconditionally discards the temp file, without checking whether its
+// content matches Oid, and marks the File closed.
+func (w *File) Cancel() error {
+	if w.Closed() {
+		return AlreadyClosed
+	}
+
+	return w.discard(fileCanceled)
+}
+
+// discard removes the temp file, if any, and transitions to state, which must
+// be fileAccepted or fileCanceled.
+func (w *File) discard(state fileState) error {
+	w.state = state
 	if w.tempFile != nil {
-		if err := cleanupFile(w.tempFile); err != nil {
-			return err
-		}
+		err := w.cleanupFile(w.tempFile)
 		w.tempFile = nil
+		return err
 	}
-
 	return nil
 }
 
-// Closed reports whether this file object has been closed.
-func (w *File) Closed() bool {
-	if w.tempFile == nil {
-		return true
+// Close cleans up the internal file objects.  It is a no-op that returns nil
+// if the File was already finished via Accept() or Cancel(); calling it twice
+// directly returns AlreadyClosed.
+func (w *File) Close() error {
+	switch w.state {
+	case fileClosed:
+		return AlreadyClosed
+	case fileAccepted, fileCanceled:
+		return nil
+	default:
+		return w.discard(fileClosed)
 	}
-	return false
 }
 
-func cleanupFile(f *os.File) error {
+// Closed reports whether this file object has reached a terminal state,
+// via Accept/Cancel/Close.
+func (w *File) Closed() bool {
+	return w.state != fileOpen
+}
+
+func (w *File) cleanupFile(f FSFile) error {
 	err := f.Close()
-	if err := os.RemoveAll(f.Name()); err != nil {
+	if err := w.fs.RemoveAll(f.Name()); err != nil {
 		return err
 	}
 
@@ -1,6 +1,10 @@
 package contentaddressable
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,6 +16,12 @@ import (
 
 var supOid = "a2b71d6ee8997eb87b25ab42d566c44f6a32871752c7c73eb5578cb1182f7be0"
 
+func sumHex(newHash func() hash.Hash, data string) string {
+	h := newHash()
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func TestFile(t *testing.T) {
 	test := SetupFile(t)
 	defer test.Teardown()
@@ -104,13 +114,177 @@ func TestFileCancel(t *testing.T) {
 	assertEqual(t, nil, err)
 	assertEqual(t, 3, n)
 
-	assertEqual(t, nil, aw.Close())
+	assertEqual(t, nil, aw.Cancel())
 
 	for _, name := range []string{aw.filename, aw.tempFilename} {
 		if _, err := os.Stat(name); err == nil {
 			t.Errorf("%s exists?", name)
 		}
 	}
+
+	// Close() after Cancel() is a no-op, not AlreadyClosed.
+	assertEqual(t, nil, aw.Close())
+}
+
+func TestFileCancelAfterAccept(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	assertEqual(t, AlreadyClosed, aw.Cancel())
+}
+
+func TestFileDoubleClose(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	assertEqual(t, nil, aw.Close())
+	assertEqual(t, AlreadyClosed, aw.Close())
+}
+
+func TestFileAcceptContextCanceled(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	created, err := aw.AcceptContext(ctx)
+	assertEqual(t, false, created)
+	assertEqual(t, context.Canceled, err)
+
+	if _, err := os.Stat(filename); err == nil {
+		t.Fatalf("%s should not exist", filename)
+	}
+
+	// The File was discarded by the canceled Accept, so Close() is a no-op.
+	assertEqual(t, nil, aw.Close())
+}
+
+// syncSpy wraps a FSFile and counts calls to Sync, so tests can verify
+// Durable actually asks the OS to flush without relying on real fsync
+// semantics (which the test filesystem can't observe).
+type syncSpy struct {
+	FSFile
+	syncs int
+}
+
+func (s *syncSpy) Sync() error {
+	s.syncs++
+	return s.FSFile.Sync()
+}
+
+func TestFileDurableAcceptSyncs(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	spy := &syncSpy{FSFile: aw.tempFile}
+	aw.tempFile = spy
+	aw.Durable = true
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	if spy.syncs != 1 {
+		t.Errorf("Expected temp file to be synced once, got %d", spy.syncs)
+	}
+}
+
+func TestFileNonDurableAcceptDoesNotSync(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	spy := &syncSpy{FSFile: aw.tempFile}
+	aw.tempFile = spy
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	if spy.syncs != 0 {
+		t.Errorf("Expected no syncs without Durable, got %d", spy.syncs)
+	}
+}
+
+func BenchmarkAccept(b *testing.B) {
+	benchmarkAccept(b, false)
+}
+
+func BenchmarkAcceptDurable(b *testing.B) {
+	benchmarkAccept(b, true)
+}
+
+func benchmarkAccept(b *testing.B, durable bool) {
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	dir := filepath.Join(wd, "BenchmarkAccept")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("SUP")
+
+	for i := 0; i < b.N; i++ {
+		filename := filepath.Join(dir, supOid)
+
+		aw, err := NewFile(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+		aw.Durable = durable
+
+		if _, err := aw.Write(data); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := aw.Accept(); err != nil {
+			b.Fatal(err)
+		}
+
+		os.RemoveAll(filename)
+	}
 }
 
 func TestFileDuel(t *testing.T) {
@@ -127,6 +301,128 @@ func TestFileDuel(t *testing.T) {
 	}
 }
 
+func TestFileAlgorithms(t *testing.T) {
+	for _, algorithm := range []string{"sha1", "sha256", "sha512", "blake2b"} {
+		t.Run(algorithm, func(t *testing.T) {
+			test := SetupFile(t)
+			defer test.Teardown()
+
+			sum := sumHex(algorithms[algorithm], "SUP")
+			filename := filepath.Join(test.Path, algorithm+"-"+sum)
+
+			aw, err := NewFile(filename)
+			assertEqual(t, nil, err)
+			assertEqual(t, algorithm, aw.Oid.Algorithm)
+			assertEqual(t, sum, aw.Oid.Hex)
+
+			n, err := aw.Write([]byte("SUP"))
+			assertEqual(t, nil, err)
+			assertEqual(t, 3, n)
+
+			created, err := aw.Accept()
+			assertEqual(t, nil, err)
+			assertEqual(t, true, created)
+
+			assertEqual(t, nil, aw.Close())
+		})
+	}
+}
+
+func TestFileAlgorithmMismatch(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	// A sha256 sum presented with a sha1 prefix will never match the sha1
+	// digest of the same bytes.
+	filename := filepath.Join(test.Path, "sha1-"+supOid[:40])
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, false, created)
+	if err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+		t.Errorf("Expected mismatch error: %s", err)
+	}
+
+	assertEqual(t, nil, aw.Close())
+}
+
+func TestFileConfigNewHashOverridesPrefixLabel(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	// The filename carries a recognized "sha1-" prefix, but cfg.NewHash
+	// supplies a custom hasher that has nothing to do with SHA-1. The
+	// prefix must not relabel Oid.Algorithm as "sha1" in that case, since
+	// the digest was never verified against SHA-1.
+	sum := sumHex(md5.New, "SUP")
+	filename := filepath.Join(test.Path, "sha1-"+sum)
+
+	aw, err := NewWithConfig(filename, Config{NewHash: md5.New})
+	assertEqual(t, nil, err)
+	assertEqual(t, DefaultAlgorithm, aw.Oid.Algorithm)
+	assertEqual(t, sum, aw.Oid.Hex)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	assertEqual(t, nil, aw.Close())
+}
+
+func TestFileConfigDefaultAlgorithm(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	sum := sumHex(algorithms["sha512"], "SUP")
+	filename := filepath.Join(test.Path, sum)
+
+	aw, err := NewWithConfig(filename, Config{Algorithm: "sha512"})
+	assertEqual(t, nil, err)
+	assertEqual(t, "sha512", aw.Oid.Algorithm)
+
+	_, err = aw.Write([]byte("SUP"))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	assertEqual(t, nil, aw.Close())
+}
+
+func TestFileMixedAlgorithmDirectory(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	for _, algorithm := range []string{"sha1", "sha256", "blake2b"} {
+		sum := sumHex(algorithms[algorithm], algorithm)
+		filename := filepath.Join(test.Path, algorithm+"-"+sum)
+
+		aw, err := NewFile(filename)
+		assertEqual(t, nil, err)
+
+		_, err = aw.Write([]byte(algorithm))
+		assertEqual(t, nil, err)
+
+		created, err := aw.Accept()
+		assertEqual(t, nil, err)
+		assertEqualf(t, true, created, "algorithm %s", algorithm)
+
+		assertEqual(t, nil, aw.Close())
+
+		by, err := ioutil.ReadFile(filename)
+		assertEqual(t, nil, err)
+		assertEqual(t, algorithm, string(by))
+	}
+}
+
 func SetupFile(t *testing.T) *FileTest {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,64 @@
+package contentaddressable
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// FSFile is the subset of *os.File that File and Reader need from an FS:
+// enough to write, read, and durably flush a single file.
+type FSFile interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+	Close() error
+	Name() string
+	Sync() error
+}
+
+// FS abstracts the filesystem operations File and Reader use, so callers can
+// plug in something other than the local disk: an in-memory filesystem for
+// fast tests, or an overlay like afero, billy, or an S3-backed store that
+// emulates rename via copy+delete.
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (FSFile, error)
+	Open(name string) (FSFile, error)
+	Rename(oldpath, newpath string) error
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	RemoveAll(path string) error
+}
+
+// OSFS implements FS on top of the local filesystem via the os package. It
+// is the default FS used by NewFile and friends.
+type OSFS struct{}
+
+func (OSFS) OpenFile(name string, flag int, perm os.FileMode) (FSFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (OSFS) Open(name string) (FSFile, error) {
+	return os.Open(name)
+}
+
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OSFS) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+// ReadDir lists the entries of a directory. It isn't part of FS itself,
+// since not every backend can support it cheaply, but callers like
+// store.Store type-assert for it to walk a sharded layout.
+func (OSFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
@@ -0,0 +1,354 @@
+package contentaddressable_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	ca "github.com/jatoben/go-contentaddressable"
+	"github.com/jatoben/go-contentaddressable/memfs"
+	"golang.org/x/crypto/blake2b"
+)
+
+// fsBackends is the table of FS implementations that File and Reader are
+// expected to behave identically against.
+var fsBackends = map[string]func() ca.FS{
+	"OSFS":  func() ca.FS { return ca.OSFS{} },
+	"MemFS": func() ca.FS { return memfs.New() },
+}
+
+// algorithmHashes mirrors the algorithm names ca.Config accepts, so tests can
+// compute an expected digest without reaching into the unexported table the
+// package itself uses.
+var algorithmHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake2b": func() hash.Hash {
+		h, err := blake2b.New256(nil)
+		if err != nil {
+			panic(err)
+		}
+		return h
+	},
+}
+
+func sumHex(newHash func() hash.Hash, data string) string {
+	h := newHash()
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fsSyncSpy wraps a ca.FS, counting Sync calls across every file and
+// directory handle it hands out. This lets tests verify File.Durable
+// actually flushes, regardless of which backend is under test.
+type fsSyncSpy struct {
+	ca.FS
+	syncs int32
+}
+
+func (s *fsSyncSpy) OpenFile(name string, flag int, perm os.FileMode) (ca.FSFile, error) {
+	f, err := s.FS.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &syncSpyFile{FSFile: f, spy: s}, nil
+}
+
+func (s *fsSyncSpy) Open(name string) (ca.FSFile, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &syncSpyFile{FSFile: f, spy: s}, nil
+}
+
+type syncSpyFile struct {
+	ca.FSFile
+	spy *fsSyncSpy
+}
+
+func (f *syncSpyFile) Sync() error {
+	atomic.AddInt32(&f.spy.syncs, 1)
+	return f.FSFile.Sync()
+}
+
+// forEachBackend runs fn once per entry in fsBackends, handing it a fresh FS
+// and an empty directory on that backend to create files under.
+func forEachBackend(t *testing.T, fn func(t *testing.T, fs ca.FS, dir string)) {
+	for name, newFS := range fsBackends {
+		t.Run(name, func(t *testing.T) {
+			fs := newFS()
+			dir := fsBackendDir(t, fs)
+			fn(t, fs, dir)
+		})
+	}
+}
+
+func fsBackendDir(t *testing.T, fs ca.FS) string {
+	if _, ok := fs.(ca.OSFS); ok {
+		return t.TempDir()
+	}
+	return "/objects"
+}
+
+func exists(fs ca.FS, filename string) bool {
+	_, err := fs.Stat(filename)
+	return err == nil
+}
+
+func TestBackendsWriteAcceptRead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := w.Accept()
+		if err != nil || !created {
+			t.Fatalf("Accept() = %v, %v", created, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := ca.NewReaderWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		by, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(by) != "SUP" {
+			t.Fatalf("expected SUP, got %q", by)
+		}
+	})
+}
+
+func TestBackendsMismatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		filename := filepath.Join(dir, "b2b71d6ee8997eb87b25ab42d566c44f6a32871752c7c73eb5578cb1182f7be0")
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := w.Accept()
+		if created || err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+			t.Fatalf("Accept() = %v, %v; wanted a mismatch error", created, err)
+		}
+		if exists(fs, filename) {
+			t.Fatalf("%s should not exist", filename)
+		}
+	})
+}
+
+func TestBackendsCancel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+		tempFilename := filename + ca.DefaultSuffix
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Cancel(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{filename, tempFilename} {
+			if exists(fs, name) {
+				t.Errorf("%s exists after Cancel", name)
+			}
+		}
+
+		// Close() after Cancel() is a no-op, not AlreadyClosed.
+		if err := w.Close(); err != nil {
+			t.Errorf("Close() after Cancel() = %v, want nil", err)
+		}
+	})
+}
+
+func TestBackendsDoubleClose(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != ca.AlreadyClosed {
+			t.Fatalf("second Close() = %v, want AlreadyClosed", err)
+		}
+	})
+}
+
+func TestBackendsAcceptContextCanceled(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		created, err := w.AcceptContext(ctx)
+		if created || err != context.Canceled {
+			t.Fatalf("AcceptContext(canceled) = %v, %v; want false, context.Canceled", created, err)
+		}
+		if exists(fs, filename) {
+			t.Fatalf("%s should not exist", filename)
+		}
+
+		// The File was discarded by the canceled Accept, so Close() is a no-op.
+		if err := w.Close(); err != nil {
+			t.Errorf("Close() after canceled Accept = %v, want nil", err)
+		}
+	})
+}
+
+func TestBackendsDuel(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(fs, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+
+		if _, err := ca.NewWithFS(fs, filename, ca.Config{}); err == nil {
+			t.Error("expected a file open conflict")
+		}
+	})
+}
+
+func TestBackendsAlgorithms(t *testing.T) {
+	for algorithm, newHash := range algorithmHashes {
+		t.Run(algorithm, func(t *testing.T) {
+			forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+				sum := sumHex(newHash, "SUP")
+				filename := filepath.Join(dir, algorithm+"-"+sum)
+
+				w, err := ca.NewWithFS(fs, filename, ca.Config{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := w.Oid.Algorithm; got != algorithm {
+					t.Errorf("Oid.Algorithm = %q, want %q", got, algorithm)
+				}
+
+				if _, err := w.Write([]byte("SUP")); err != nil {
+					t.Fatal(err)
+				}
+
+				created, err := w.Accept()
+				if err != nil || !created {
+					t.Fatalf("Accept() = %v, %v", created, err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			})
+		})
+	}
+}
+
+func TestBackendsDurableAcceptSyncs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		spy := &fsSyncSpy{FS: fs}
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(spy, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Durable = true
+
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := w.Accept()
+		if err != nil || !created {
+			t.Fatalf("Accept() = %v, %v", created, err)
+		}
+
+		if atomic.LoadInt32(&spy.syncs) == 0 {
+			t.Error("Durable Accept did not Sync any handle")
+		}
+	})
+}
+
+func TestBackendsNonDurableAcceptDoesNotSync(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, fs ca.FS, dir string) {
+		spy := &fsSyncSpy{FS: fs}
+		sum := sumHex(sha256.New, "SUP")
+		filename := filepath.Join(dir, sum)
+
+		w, err := ca.NewWithFS(spy, filename, ca.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("SUP")); err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := w.Accept()
+		if err != nil || !created {
+			t.Fatalf("Accept() = %v, %v", created, err)
+		}
+
+		if n := atomic.LoadInt32(&spy.syncs); n != 0 {
+			t.Errorf("non-Durable Accept synced %d handles, want 0", n)
+		}
+	})
+}
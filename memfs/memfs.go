@@ -0,0 +1,260 @@
+// Package memfs implements an in-memory contentaddressable.FS, for tests
+// that want File/Reader round trips without touching a real filesystem.
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	ca "github.com/jatoben/go-contentaddressable"
+)
+
+// FS is an in-memory contentaddressable.FS. The zero value is not usable;
+// construct one with New.
+type FS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+// New returns an empty FS.
+func New() *FS {
+	return &FS{
+		files: make(map[string][]byte),
+		dirs:  map[string]bool{"/": true},
+	}
+}
+
+// OpenFile implements contentaddressable.FS.
+func (fs *FS) OpenFile(name string, flag int, perm os.FileMode) (ca.FSFile, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if flag&os.O_CREATE != 0 {
+		if _, exists := fs.files[name]; exists && flag&os.O_EXCL != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		// Reserve the name immediately, the same way os.OpenFile creates the
+		// file on disk before any bytes are written to it.
+		fs.files[name] = nil
+	}
+
+	return &writeFile{fs: fs, name: name}, nil
+}
+
+// Open implements contentaddressable.FS.
+func (fs *FS) Open(name string) (ca.FSFile, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if data, ok := fs.files[name]; ok {
+		return &readFile{name: name, r: bytes.NewReader(data)}, nil
+	}
+	if fs.dirs[name] {
+		return &dirFile{name: name}, nil
+	}
+
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// Rename implements contentaddressable.FS.
+func (fs *FS) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, ok := fs.files[oldpath]
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+
+	fs.files[newpath] = data
+	delete(fs.files, oldpath)
+	fs.markDirs(path.Dir(newpath))
+	return nil
+}
+
+// Stat implements contentaddressable.FS.
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, ok := fs.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	return fileInfo{name: path.Base(name), size: int64(len(data))}, nil
+}
+
+// MkdirAll implements contentaddressable.FS.
+func (fs *FS) MkdirAll(dir string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.markDirs(dir)
+	return nil
+}
+
+// RemoveAll implements contentaddressable.FS.
+func (fs *FS) RemoveAll(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.files, name)
+	delete(fs.dirs, name)
+
+	prefix := name + "/"
+	for k := range fs.files {
+		if strings.HasPrefix(k, prefix) {
+			delete(fs.files, k)
+		}
+	}
+	return nil
+}
+
+// ReadDir lists the immediate children of dir, so callers like store.Store
+// can walk a sharded layout the same way they would against OSFS.
+func (fs *FS) ReadDir(dir string) ([]os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if !fs.dirs[dir] {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: os.ErrNotExist}
+	}
+
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	seen := make(map[string]bool)
+	var entries []os.FileInfo
+
+	for name, data := range fs.files {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if strings.Contains(rest, "/") {
+			continue
+		}
+		seen[rest] = true
+		entries = append(entries, fileInfo{name: rest, size: int64(len(data))})
+	}
+
+	for d := range fs.dirs {
+		if !strings.HasPrefix(d, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(d, prefix)
+		if rest == "" || strings.Contains(rest, "/") || seen[rest] {
+			continue
+		}
+		entries = append(entries, fileInfo{name: rest, size: 0, isDir: true})
+	}
+
+	return entries, nil
+}
+
+func (fs *FS) markDirs(dir string) {
+	for d := dir; d != "" && d != "." && d != "/" && !fs.dirs[d]; d = path.Dir(d) {
+		fs.dirs[d] = true
+	}
+}
+
+// writeFile is the handle returned by OpenFile. Its content is only visible
+// to the rest of FS once it's closed, mirroring how a real temp file's
+// content isn't durable until it's flushed.
+type writeFile struct {
+	fs     *FS
+	name   string
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return 0, errors.New("memfs: write handle is not readable")
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.buf.Write(p)
+}
+
+func (f *writeFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	data := make([]byte, f.buf.Len())
+	copy(data, f.buf.Bytes())
+	f.fs.files[f.name] = data
+	return nil
+}
+
+func (f *writeFile) Name() string { return f.name }
+func (f *writeFile) Sync() error  { return nil }
+
+// readFile is the handle returned by Open for a regular file.
+type readFile struct {
+	name   string
+	r      *bytes.Reader
+	closed bool
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	return f.r.Read(p)
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, errors.New("memfs: read handle is not writable")
+}
+
+func (f *readFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *readFile) Name() string { return f.name }
+func (f *readFile) Sync() error  { return nil }
+
+// dirFile is the handle returned by Open for a directory, so File's
+// Durable option can "sync" it as a no-op.
+type dirFile struct {
+	name string
+}
+
+func (f *dirFile) Read(p []byte) (int, error) { return 0, errors.New("memfs: cannot read a directory") }
+func (f *dirFile) Write(p []byte) (int, error) {
+	return 0, errors.New("memfs: cannot write a directory")
+}
+func (f *dirFile) Close() error { return nil }
+func (f *dirFile) Name() string { return f.name }
+func (f *dirFile) Sync() error  { return nil }
+
+type fileInfo struct {
+	name  string
+	size  int64
+	isDir bool
+}
+
+func (i fileInfo) Name() string { return i.name }
+func (i fileInfo) Size() int64  { return i.size }
+func (i fileInfo) Mode() os.FileMode {
+	if i.isDir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.isDir }
+func (i fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,78 @@
+package memfs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFSWriteRenameRead(t *testing.T) {
+	fs := New()
+
+	if err := fs.MkdirAll("/objects", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	wf, err := fs.OpenFile("/objects/sup-temp", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wf.Write([]byte("SUP")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Rename("/objects/sup-temp", "/objects/sup"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat("/objects/sup"); err != nil {
+		t.Fatalf("expected /objects/sup to exist: %s", err)
+	}
+
+	rf, err := fs.Open("/objects/sup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	by, err := ioutil.ReadAll(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(by) != "SUP" {
+		t.Fatalf("expected SUP, got %q", by)
+	}
+}
+
+func TestFSOpenFileExcl(t *testing.T) {
+	fs := New()
+
+	if _, err := fs.OpenFile("/sup", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.OpenFile("/sup", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !os.IsExist(err) {
+		t.Errorf("expected an already-exists error, got %v", err)
+	}
+}
+
+func TestFSRemoveAll(t *testing.T) {
+	fs := New()
+
+	wf, err := fs.OpenFile("/sup", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf.Close()
+
+	if err := fs.RemoveAll("/sup"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat("/sup"); !os.IsNotExist(err) {
+		t.Errorf("expected /sup to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package contentaddressable
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Reader reads an existing content addressable file, streaming its bytes
+// through the same hash used to write it, and verifies the accumulated
+// digest against the OID encoded in the filename.
+type Reader struct {
+	Oid      Oid
+	file     FSFile
+	hasher   hash.Hash
+	verified bool
+	err      error
+	closed   bool
+}
+
+// NewReader opens filename for reading as a content addressable file.  It is
+// identical to NewReaderWithConfig, except it uses an empty Config.
+func NewReader(filename string) (*Reader, error) {
+	return NewReaderWithConfig(filename, Config{})
+}
+
+// NewReaderWithConfig opens filename for reading as a content addressable
+// file, using cfg to pick the hash algorithm the same way NewWithConfig does.
+func NewReaderWithConfig(filename string, cfg Config) (*Reader, error) {
+	return NewReaderWithFS(OSFS{}, filename, cfg)
+}
+
+// NewReaderWithFS opens filename for reading as a content addressable file,
+// performing all filesystem operations through fs instead of the local disk.
+func NewReaderWithFS(fs FS, filename string, cfg Config) (*Reader, error) {
+	oid, newHash, err := parseOid(filepath.Base(filename), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{Oid: oid, file: file, hasher: newHash()}, nil
+}
+
+// Read streams bytes from the underlying file, feeding them through the
+// hasher.  Once the file is exhausted, Read verifies the accumulated digest
+// against r.Oid: on success it returns io.EOF as usual, but on a mismatch it
+// returns the mismatch error instead.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, AlreadyClosed
+	}
+
+	n, err := r.file.Read(p)
+	if n > 0 {
+		r.hasher.Write(p[:n])
+	}
+
+	if err == io.EOF {
+		if verr := r.verify(); verr != nil {
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+// Close verifies the accumulated digest (reading any remaining bytes first if
+// the file wasn't read to completion) and closes the underlying file.  It
+// returns a mismatch error if the content doesn't match r.Oid.
+func (r *Reader) Close() error {
+	if r.closed {
+		return AlreadyClosed
+	}
+	r.closed = true
+
+	if !r.verified {
+		if _, err := io.Copy(r.hasher, r.file); err != nil {
+			r.err = err
+		} else {
+			r.err = r.verify()
+		}
+	}
+
+	cerr := r.file.Close()
+	if r.err != nil {
+		return r.err
+	}
+	return cerr
+}
+
+func (r *Reader) verify() error {
+	if r.verified {
+		return r.err
+	}
+	r.verified = true
+
+	sig := hex.EncodeToString(r.hasher.Sum(nil))
+	if sig != r.Oid.Hex {
+		r.err = fmt.Errorf("Content mismatch.  Expected %s OID %s, got %s", r.Oid.Algorithm, r.Oid.Hex, sig)
+	}
+	return r.err
+}
+
+// Verify reads filename to EOF as a content addressable file and returns any
+// error encountered, including a digest mismatch.
+func Verify(filename string) error {
+	r, err := NewReader(filename)
+	if err != nil {
+		return err
+	}
+
+	_, cerr := io.Copy(ioutil.Discard, r)
+	if err = r.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package contentaddressable
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeAcceptedFile(t *testing.T, dir, oid, content string) string {
+	filename := filepath.Join(dir, oid)
+	aw, err := NewFile(filename)
+	assertEqual(t, nil, err)
+
+	_, err = aw.Write([]byte(content))
+	assertEqual(t, nil, err)
+
+	created, err := aw.Accept()
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	assertEqual(t, nil, aw.Close())
+	return filename
+}
+
+func TestReader(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+
+	r, err := NewReader(filename)
+	assertEqual(t, nil, err)
+
+	by, err := ioutil.ReadAll(r)
+	assertEqual(t, nil, err)
+	assertEqual(t, "SUP", string(by))
+
+	assertEqual(t, nil, r.Close())
+}
+
+func TestReaderTruncated(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+	assertEqual(t, nil, ioutil.WriteFile(filename, []byte("SU"), 0644))
+
+	r, err := NewReader(filename)
+	assertEqual(t, nil, err)
+
+	_, err = ioutil.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+		t.Errorf("Expected mismatch error: %s", err)
+	}
+
+	if err := r.Close(); err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+		t.Errorf("Expected mismatch error from Close: %s", err)
+	}
+}
+
+func TestReaderBitFlipped(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+	assertEqual(t, nil, ioutil.WriteFile(filename, []byte("SUQ"), 0644))
+
+	if err := Verify(filename); err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+		t.Errorf("Expected mismatch error: %s", err)
+	}
+}
+
+func TestReaderCloseWithoutFullRead(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+
+	r, err := NewReader(filename)
+	assertEqual(t, nil, err)
+
+	buf := make([]byte, 1)
+	n, err := r.Read(buf)
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, n)
+
+	// Close should drain and verify the rest even though we didn't read to
+	// EOF ourselves.
+	assertEqual(t, nil, r.Close())
+}
+
+func TestReaderAfterClose(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+
+	r, err := NewReader(filename)
+	assertEqual(t, nil, err)
+	assertEqual(t, nil, r.Close())
+
+	_, err = r.Read(make([]byte, 1))
+	assertEqual(t, AlreadyClosed, err)
+	assertEqual(t, AlreadyClosed, r.Close())
+}
+
+func TestVerify(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := writeAcceptedFile(t, test.Path, supOid, "SUP")
+	assertEqual(t, nil, Verify(filename))
+}
+
+var _ io.Reader = (*Reader)(nil)
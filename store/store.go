@@ -0,0 +1,178 @@
+// Package store organizes content addressable files into a sharded
+// directory tree, the way Git lays out its objects/ directory and Git LFS
+// lays out its object store: ab/cd/ef/abcdef... by default.
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ca "github.com/jatoben/go-contentaddressable"
+)
+
+// DefaultShardLengths splits an OID into three 2-character shard
+// directories before the full OID filename, matching Git and Git LFS.
+var DefaultShardLengths = []int{2, 2, 2}
+
+// StoreOptions configures a Store.
+type StoreOptions struct {
+	// FS performs the Store's filesystem operations. Defaults to ca.OSFS{}.
+	FS ca.FS
+
+	// Config picks the hash algorithm used to verify objects. Defaults to
+	// an empty ca.Config, i.e. ca.DefaultAlgorithm. Store OIDs must be plain
+	// lowercase hex, so they never carry an "<algorithm>-" prefix.
+	Config ca.Config
+
+	// ShardLengths gives the length of each shard directory, applied in
+	// order to successive prefixes of the OID. Defaults to
+	// DefaultShardLengths. An empty slice disables sharding.
+	ShardLengths []int
+}
+
+// Store lays content addressable files out under root using a sharded
+// directory tree, so that no single directory ends up with millions of
+// entries.
+type Store struct {
+	root         string
+	fs           ca.FS
+	cfg          ca.Config
+	shardLengths []int
+}
+
+// New returns a Store rooted at root, configured by opts.
+func New(root string, opts StoreOptions) *Store {
+	fs := opts.FS
+	if fs == nil {
+		fs = ca.OSFS{}
+	}
+
+	shardLengths := opts.ShardLengths
+	if shardLengths == nil {
+		shardLengths = DefaultShardLengths
+	}
+
+	return &Store{
+		root:         root,
+		fs:           fs,
+		cfg:          opts.Config,
+		shardLengths: shardLengths,
+	}
+}
+
+// Path returns the sharded on-disk path for oid, without checking whether it
+// exists. An oid shorter than the configured shard lengths is sharded as far
+// as it goes, then used whole as the filename. Path assumes oid has already
+// been validated by validOid; Create, Open, and Has do that before calling
+// it, so a malicious oid never reaches a filesystem path.
+func (s *Store) Path(oid string) string {
+	dir := s.root
+	rest := oid
+
+	for _, n := range s.shardLengths {
+		if len(rest) < n {
+			break
+		}
+		dir = filepath.Join(dir, rest[:n])
+		rest = rest[n:]
+	}
+
+	return filepath.Join(dir, oid)
+}
+
+// validOid reports whether oid is safe to build a filesystem path from: a
+// non-empty run of lowercase hex digits. Callers (an LFS object PUT handler,
+// say) often pass an oid straight from a URL path, so this rejects anything
+// that could smuggle a path separator or a ".." segment into the Store's
+// layout, escaping its root.
+func validOid(oid string) bool {
+	if oid == "" {
+		return false
+	}
+	for i := 0; i < len(oid); i++ {
+		c := oid[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Create begins writing a new object for oid, sharded under the Store's
+// root. Like ca.NewWithFS, the returned File must be Write'n and Accept'ed
+// or Cancel'ed by the caller.
+func (s *Store) Create(oid string) (*ca.File, error) {
+	if !validOid(oid) {
+		return nil, fmt.Errorf("store: invalid oid %q", oid)
+	}
+	return ca.NewWithFS(s.fs, s.Path(oid), s.cfg)
+}
+
+// Open opens the object for oid for reading, verifying its content against
+// oid as it's read.
+func (s *Store) Open(oid string) (*ca.Reader, error) {
+	if !validOid(oid) {
+		return nil, fmt.Errorf("store: invalid oid %q", oid)
+	}
+	return ca.NewReaderWithFS(s.fs, s.Path(oid), s.cfg)
+}
+
+// Has reports whether an object for oid exists in the Store.
+func (s *Store) Has(oid string) bool {
+	if !validOid(oid) {
+		return false
+	}
+	_, err := s.fs.Stat(s.Path(oid))
+	return err == nil
+}
+
+// dirReader is implemented by ca.OSFS and memfs.FS, letting Iter walk a
+// Store without requiring every ca.FS implementation to support listing.
+type dirReader interface {
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// Iter walks every object in the Store, in an unspecified order, calling fn
+// with each OID. It stops and returns fn's error as soon as fn returns one.
+func (s *Store) Iter(fn func(oid string) error) error {
+	dr, ok := s.fs.(dirReader)
+	if !ok {
+		return fmt.Errorf("store: %T does not support iteration", s.fs)
+	}
+
+	return s.walk(dr, s.root, fn)
+}
+
+func (s *Store) walk(dr dirReader, dir string, fn func(oid string) error) error {
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := s.walk(dr, filepath.Join(dir, entry.Name()), fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Create leaves its in-progress temp file (oid+ca.DefaultSuffix) in
+		// the same shard directory until Accept renames it away. Skip it so
+		// Iter never reports a half-written object as a finished one.
+		if strings.HasSuffix(entry.Name(), ca.DefaultSuffix) {
+			continue
+		}
+
+		if err := fn(entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	ca "github.com/jatoben/go-contentaddressable"
+	"github.com/jatoben/go-contentaddressable/memfs"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStorePath(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	oid := "abcdef0123456789"
+	if got, want := s.Path(oid), "/objects/ab/cd/ef/abcdef0123456789"; got != want {
+		t.Errorf("Path(%q) = %q, want %q", oid, got, want)
+	}
+}
+
+func TestStorePathShortOid(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	cases := map[string]string{
+		"ab": "/objects/ab/ab",
+		"a":  "/objects/a",
+		"":   "/objects",
+	}
+	for oid, want := range cases {
+		if got := s.Path(oid); got != want {
+			t.Errorf("Path(%q) = %q, want %q", oid, got, want)
+		}
+	}
+}
+
+func TestStoreRejectsInvalidOid(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	invalid := []string{
+		"",
+		"../../../../tmp/pwned_outside_root",
+		"ab/cd",
+		"ab\\cd",
+		"..",
+		"ABCDEF",
+		"not-hex",
+	}
+
+	for _, oid := range invalid {
+		if s.Has(oid) {
+			t.Errorf("Has(%q) = true, want false", oid)
+		}
+		if _, err := s.Create(oid); err == nil {
+			t.Errorf("Create(%q) = nil error, want rejection", oid)
+		}
+		if _, err := s.Open(oid); err == nil {
+			t.Errorf("Open(%q) = nil error, want rejection", oid)
+		}
+	}
+}
+
+func TestStoreCreateOpenHas(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	oid := sha256Hex("SUP")
+	if s.Has(oid) {
+		t.Fatalf("Has(%q) = true before Create", oid)
+	}
+
+	w, err := s.Create(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("SUP")); err != nil {
+		t.Fatal(err)
+	}
+	created, err := w.Accept()
+	if err != nil || !created {
+		t.Fatalf("Accept() = %v, %v", created, err)
+	}
+
+	if !s.Has(oid) {
+		t.Fatalf("Has(%q) = false after Accept", oid)
+	}
+
+	r, err := s.Open(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	by, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(by) != "SUP" {
+		t.Fatalf("expected SUP, got %q", by)
+	}
+}
+
+func TestStoreIter(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	contents := []string{"SUP", "NOPE", "MAYBE"}
+	oids := make([]string, len(contents))
+	for i, data := range contents {
+		oids[i] = sha256Hex(data)
+
+		w, err := s.Create(oids[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Accept(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	if err := s.Iter(func(oid string) error {
+		seen[oid] = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, oid := range oids {
+		if !seen[oid] {
+			t.Errorf("Iter did not visit %q", oid)
+		}
+	}
+	if len(seen) != len(oids) {
+		t.Errorf("Iter visited %d objects, want %d", len(seen), len(oids))
+	}
+}
+
+func TestStoreIterSkipsInProgressWrite(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+
+	done := sha256Hex("DONE")
+	w, err := s.Create(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("DONE")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Accept(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave this one open, neither Accept'ed nor Cancel'ed, so its temp file
+	// still sits in the shard directory.
+	inProgress := sha256Hex("IN PROGRESS")
+	pending, err := s.Create(inProgress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pending.Write([]byte("IN PROGRESS")); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	if err := s.Iter(func(oid string) error {
+		seen[oid] = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !seen[done] {
+		t.Errorf("Iter did not visit finished object %q", done)
+	}
+	if len(seen) != 1 {
+		t.Errorf("Iter visited %v, want only %q", seen, done)
+	}
+}
+
+func TestStoreConcurrentCreateSameOid(t *testing.T) {
+	s := New("/objects", StoreOptions{FS: memfs.New()})
+	oid := sha256Hex("SUP")
+
+	const n = 8
+	var wg sync.WaitGroup
+	successes := make(chan bool, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, err := s.Create(oid)
+			if err != nil {
+				successes <- false
+				return
+			}
+			if _, err := w.Write([]byte("SUP")); err != nil {
+				w.Cancel()
+				successes <- false
+				return
+			}
+			created, err := w.Accept()
+			successes <- err == nil && created
+		}()
+	}
+	wg.Wait()
+	close(successes)
+
+	wins := 0
+	for ok := range successes {
+		if ok {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Errorf("expected exactly 1 winning Create, got %d", wins)
+	}
+
+	if !s.Has(oid) {
+		t.Fatalf("Has(%q) = false after concurrent Create", oid)
+	}
+
+	r, err := s.Open(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	by, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(by) != "SUP" {
+		t.Fatalf("expected SUP, got %q", by)
+	}
+}
+
+func TestStoreOSFSNotFound(t *testing.T) {
+	s := New("/does-not-exist", StoreOptions{FS: ca.OSFS{}})
+
+	if s.Has("deadbeef") {
+		t.Fatal("Has() = true for a store with no root directory")
+	}
+
+	if err := s.Iter(func(oid string) error { return nil }); err != nil {
+		t.Errorf("Iter() on a missing root should be a no-op, got %v", err)
+	}
+}
+
+func TestStoreCreateRejectsPathTraversalOnOSFS(t *testing.T) {
+	root := t.TempDir()
+	outsideRoot := filepath.Join(filepath.Dir(root), "pwned_outside_root")
+	defer os.Remove(outsideRoot)
+
+	s := New(root, StoreOptions{FS: ca.OSFS{}})
+
+	oid := filepath.Join("../../../../", outsideRoot)
+	if _, err := s.Create(oid); err == nil {
+		t.Fatalf("Create(%q) = nil error, want rejection", oid)
+	}
+
+	if _, err := os.Stat(outsideRoot); err == nil {
+		t.Fatalf("Create(%q) escaped the store root and created %s", oid, outsideRoot)
+	}
+}
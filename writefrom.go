@@ -0,0 +1,44 @@
+package contentaddressable
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrSizeExceeded is returned by WriteFrom and WriteFromContext when the
+// reader produces more than maxBytes, so callers can distinguish an oversize
+// upload from a hash mismatch.
+var ErrSizeExceeded = errors.New("Content exceeds maximum size.")
+
+// WriteFrom is a one-liner cache-put: it writes r to filename as a content
+// addressable file, verifying and accepting it in a single pass, without the
+// caller having to juggle Write/Accept/Close directly.  It is identical to
+// WriteFromContext, except it uses context.Background().
+func WriteFrom(filename string, r io.Reader, maxBytes int64) (created bool, err error) {
+	return WriteFromContext(context.Background(), filename, r, maxBytes)
+}
+
+// WriteFromContext is like WriteFrom, but honors ctx's cancellation while
+// finalizing, via File.AcceptContext.  r is read through an io.LimitReader
+// capped at maxBytes+1: if that cap is reached, WriteFromContext returns
+// ErrSizeExceeded instead of hashing and accepting a truncated result.
+func WriteFromContext(ctx context.Context, filename string, r io.Reader, maxBytes int64) (created bool, err error) {
+	w, err := NewFile(filename)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := io.Copy(w, io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		w.Cancel()
+		return false, err
+	}
+
+	if n > maxBytes {
+		w.Cancel()
+		return false, ErrSizeExceeded
+	}
+
+	return w.AcceptContext(ctx)
+}
@@ -0,0 +1,67 @@
+package contentaddressable
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFrom(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	created, err := WriteFrom(filename, strings.NewReader("SUP"), 1024)
+	assertEqual(t, nil, err)
+	assertEqual(t, true, created)
+
+	by, err := ioutil.ReadFile(filename)
+	assertEqual(t, nil, err)
+	assertEqual(t, "SUP", string(by))
+}
+
+func TestWriteFromMismatch(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, "b2b71d6ee8997eb87b25ab42d566c44f6a32871752c7c73eb5578cb1182f7be0")
+	created, err := WriteFrom(filename, strings.NewReader("SUP"), 1024)
+	assertEqual(t, false, created)
+	if err == nil || !strings.Contains(err.Error(), "Content mismatch") {
+		t.Errorf("Expected mismatch error: %s", err)
+	}
+
+	if _, err := ioutil.ReadFile(filename); !os.IsNotExist(err) {
+		t.Fatalf("%s should not exist", filename)
+	}
+}
+
+func TestWriteFromSizeExceeded(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	created, err := WriteFrom(filename, strings.NewReader("SUP"), 2)
+	assertEqual(t, false, created)
+	assertEqual(t, ErrSizeExceeded, err)
+
+	if _, err := ioutil.ReadFile(filename); !os.IsNotExist(err) {
+		t.Fatalf("%s should not exist", filename)
+	}
+}
+
+func TestWriteFromContextCanceled(t *testing.T) {
+	test := SetupFile(t)
+	defer test.Teardown()
+
+	filename := filepath.Join(test.Path, supOid)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	created, err := WriteFromContext(ctx, filename, strings.NewReader("SUP"), 1024)
+	assertEqual(t, false, created)
+	assertEqual(t, context.Canceled, err)
+}